@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/external"
+)
+
+// fakeBackendFactory is a BackendFactory test double that ignores the
+// account manager parameter, so registerBackends' aggregation/error-wrapping
+// logic can be exercised without a real *accounts.Manager.
+type fakeBackendFactory struct {
+	name    string
+	backend accounts.Backend
+	err     error
+}
+
+func (f fakeBackendFactory) Name() string { return f.name }
+
+func (f fakeBackendFactory) Register(ctx *cli.Context, am *accounts.Manager) (accounts.Backend, error) {
+	return f.backend, f.err
+}
+
+func TestRegisterBackendsSkipsInapplicableFactories(t *testing.T) {
+	orig := backendFactories
+	defer func() { backendFactories = orig }()
+
+	backendFactories = []BackendFactory{
+		fakeBackendFactory{name: "not configured"},
+		fakeBackendFactory{name: "configured", backend: &external.ExternalBackend{}},
+	}
+
+	backends, err := registerBackends(nil, nil)
+	if err != nil {
+		t.Fatalf("registerBackends: unexpected error: %v", err)
+	}
+	if len(backends) != 1 {
+		t.Fatalf("registerBackends: got %d backends, want 1", len(backends))
+	}
+}
+
+func TestRegisterBackendsWrapsFactoryError(t *testing.T) {
+	orig := backendFactories
+	defer func() { backendFactories = orig }()
+
+	backendFactories = []BackendFactory{
+		fakeBackendFactory{name: "broken signer", err: errors.New("connection refused")},
+	}
+
+	_, err := registerBackends(nil, nil)
+	if err == nil {
+		t.Fatal("registerBackends: expected error, got nil")
+	}
+	want := "broken signer: connection refused"
+	if err.Error() != want {
+		t.Errorf("registerBackends: err = %q, want %q", err.Error(), want)
+	}
+}
+
+// TestRegisterExternalBackendAddsToRealManager exercises the actual
+// am.AddBackend call registerExternalBackend makes against a real
+// accounts.Manager, rather than a test double, so a real API mismatch here
+// (the same class of bug as the accounts.ParseURL one) would fail to
+// compile instead of going unnoticed.
+func TestRegisterExternalBackendAddsToRealManager(t *testing.T) {
+	am := accounts.NewManager(&accounts.Config{})
+	defer am.Close()
+
+	backend := &external.ExternalBackend{}
+	registerExternalBackend(am, backend)
+
+	kind := reflect.TypeOf(backend)
+	got := am.Backends(kind)
+	if len(got) != 1 || got[0] != backend {
+		t.Fatalf("registerExternalBackend: manager backends for %v = %v, want [%v]", kind, got, backend)
+	}
+}
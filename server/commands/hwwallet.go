@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+	"reflect"
+
+	"gopkg.in/urfave/cli.v1"
+
+	ethUtils "github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/usbwallet"
+	"github.com/ethereum/go-ethereum/console"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/CyberMiles/travis/modules/vm/ethereum"
+)
+
+// HWWalletFlag enables Ledger/Trezor support alongside the plaintext
+// keystore, so validator keys can be signed without ever touching disk.
+var HWWalletFlag = cli.BoolFlag{
+	Name:  "hwwallet",
+	Usage: "Enable USB hardware wallet (Ledger/Trezor) support for signing",
+}
+
+// hwWalletType singles out the usbwallet hub among the account manager's
+// registered backends, mirroring how keystore.KeyStoreType singles out the
+// plaintext keystore in keystoreBackend.
+var hwWalletType = reflect.TypeOf(&usbwallet.Hub{})
+
+// watchHardwareWallets opens every already-attached Ledger/Trezor wallet and
+// then keeps listening for new ones, prompting for a passphrase or PIN via
+// console.Stdin when a device asks for one. Opened wallets are self-derived
+// against the inproc ethclient so their default accounts become available
+// for unlocking and signing, the same way the keystore wallets are.
+func watchHardwareWallets(stack *ethereum.Node) {
+	am := stack.AccountManager()
+	if len(am.Backends(hwWalletType)) == 0 {
+		return
+	}
+
+	rpcClient, err := stack.Attach()
+	if err != nil {
+		ethUtils.Fatalf("Failed to attach to self: %v", err)
+	}
+	stateReader := ethclient.NewClient(rpcClient)
+
+	events := make(chan accounts.WalletEvent, 16)
+	am.Subscribe(events)
+
+	for _, backend := range am.Backends(hwWalletType) {
+		for _, wallet := range backend.Wallets() {
+			openHardwareWallet(wallet, stateReader)
+		}
+	}
+
+	go func() {
+		for event := range events {
+			if !event.Arrive || !isHardwareWalletURL(event.Wallet) {
+				continue
+			}
+			openHardwareWallet(event.Wallet, stateReader)
+		}
+	}()
+}
+
+func isHardwareWalletURL(wallet accounts.Wallet) bool {
+	scheme := wallet.URL().Scheme
+	return scheme == usbwallet.LedgerScheme || scheme == usbwallet.TrezorScheme
+}
+
+func openHardwareWallet(wallet accounts.Wallet, stateReader *ethclient.Client) {
+	err := wallet.Open("")
+	if err == usbwallet.ErrTrezorPINNeeded {
+		pin, perr := console.Stdin.PromptPassword(fmt.Sprintf("PIN for %s: ", wallet.URL()))
+		if perr != nil {
+			log.Warn("Failed to read hardware wallet PIN", "url", wallet.URL(), "err", perr)
+			return
+		}
+		err = wallet.Open(pin)
+	}
+	if err == usbwallet.ErrTrezorPassphraseNeeded {
+		passphrase, perr := console.Stdin.PromptPassword(fmt.Sprintf("Passphrase for %s: ", wallet.URL()))
+		if perr != nil {
+			log.Warn("Failed to read hardware wallet passphrase", "url", wallet.URL(), "err", perr)
+			return
+		}
+		err = wallet.Open(passphrase)
+	}
+	if err != nil {
+		log.Warn("Failed to open hardware wallet", "url", wallet.URL(), "err", err)
+		return
+	}
+	log.Info("Hardware wallet unlocked", "url", wallet.URL(), "status", wallet.Status())
+	wallet.SelfDerive(accounts.DefaultBaseDerivationPath, stateReader)
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/urfave/cli.v1"
+
+	ethUtils "github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/console"
+)
+
+// PasswordEnvFlag names an environment variable pattern, e.g.
+// "TRAVIS_PASSWORD_%d", holding one passphrase per --unlock slot.
+var PasswordEnvFlag = cli.StringFlag{
+	Name:  "password-env",
+	Usage: "Environment variable name pattern (e.g. TRAVIS_PASSWORD_%d) holding one passphrase per --unlock slot",
+}
+
+// PasswordFDFlag names a file descriptor to read newline-separated
+// passphrases from, suitable for systemd's LoadCredential= mechanism.
+var PasswordFDFlag = cli.IntFlag{
+	Name:  "password-fd",
+	Usage: "File descriptor to read newline-separated passphrases from, e.g. for systemd LoadCredential=",
+	Value: -1,
+}
+
+// PassphraseProvider supplies the passphrase to try for a given --unlock
+// slot. unlockAccount walks a chain of providers (file, env, fd, stdin),
+// trying each in turn so no single source being short or missing silently
+// reuses another slot's passphrase.
+type PassphraseProvider interface {
+	// Name identifies the provider in log messages and the final error.
+	Name() string
+	// Passphrase returns the passphrase to try for unlock slot i, or
+	// ok=false when this provider has nothing left to offer for that slot.
+	Passphrase(i int) (password string, ok bool)
+}
+
+// buildPassphraseProviders assembles the provider chain from --password,
+// --password-env, and --password-fd, always ending in an interactive
+// console prompt so there is a last resort even when none of the
+// non-interactive sources cover a slot.
+func buildPassphraseProviders(ctx *cli.Context) []PassphraseProvider {
+	var providers []PassphraseProvider
+
+	if path := ctx.GlobalString(ethUtils.PasswordFileFlag.Name); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			ethUtils.Fatalf("Failed to open password file %s: %v", path, err)
+		}
+		lines, err := readPasswordLines(f)
+		f.Close()
+		if err != nil {
+			ethUtils.Fatalf("Failed to read password file %s: %v", path, err)
+		}
+		providers = append(providers, &linePassphraseProvider{name: "file:" + path, lines: lines})
+	}
+
+	if pattern := ctx.GlobalString(PasswordEnvFlag.Name); pattern != "" {
+		providers = append(providers, envPassphraseProvider{pattern: pattern})
+	}
+
+	if fd := ctx.GlobalInt(PasswordFDFlag.Name); fd >= 0 {
+		pipe := os.NewFile(uintptr(fd), "password-fd")
+		lines, err := readPasswordLines(pipe)
+		pipe.Close()
+		if err != nil {
+			ethUtils.Fatalf("Failed to read passwords from fd %d: %v", fd, err)
+		}
+		providers = append(providers, &linePassphraseProvider{name: fmt.Sprintf("fd:%d", fd), lines: lines})
+	}
+
+	providers = append(providers, stdinPassphraseProvider{})
+	return providers
+}
+
+// readPasswordLines splits r into one passphrase per line. Blank lines are
+// kept rather than skipped: a line's index is its --unlock slot, and
+// dropping a blank line would shift every later slot onto the wrong
+// passphrase.
+func readPasswordLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	return lines, scanner.Err()
+}
+
+// linePassphraseProvider backs --password and --password-fd: one
+// passphrase per line, matched to the --unlock slot at the same index.
+type linePassphraseProvider struct {
+	name  string
+	lines []string
+}
+
+func (p *linePassphraseProvider) Name() string { return p.name }
+
+func (p *linePassphraseProvider) Passphrase(i int) (string, bool) {
+	if i >= len(p.lines) {
+		// Deliberately does not fall back to the last line: a source that
+		// is shorter than --unlock should be treated as exhausted for the
+		// remaining slots, not silently reused.
+		return "", false
+	}
+	return p.lines[i], true
+}
+
+// envPassphraseProvider backs --password-env: the passphrase for slot i is
+// read from the environment variable named fmt.Sprintf(pattern, i).
+type envPassphraseProvider struct {
+	pattern string
+}
+
+func (p envPassphraseProvider) Name() string { return "env:" + p.pattern }
+
+func (p envPassphraseProvider) Passphrase(i int) (string, bool) {
+	return os.LookupEnv(fmt.Sprintf(p.pattern, i))
+}
+
+// stdinPassphraseProvider is the interactive fallback: it prompts for every
+// slot, so it anchors the end of the provider chain. It reports ok=false
+// only when reading the prompt fails.
+type stdinPassphraseProvider struct{}
+
+func (stdinPassphraseProvider) Name() string { return "stdin" }
+
+func (stdinPassphraseProvider) Passphrase(i int) (string, bool) {
+	password, err := console.Stdin.PromptPassword(fmt.Sprintf("Passphrase (slot %d): ", i))
+	if err != nil {
+		return "", false
+	}
+	return password, true
+}
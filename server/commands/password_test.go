@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadPasswordLinesPreservesBlankLines(t *testing.T) {
+	input := "first\n\nthird\n"
+	lines, err := readPasswordLines(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("readPasswordLines: unexpected error: %v", err)
+	}
+	want := []string{"first", "", "third"}
+	if len(lines) != len(want) {
+		t.Fatalf("readPasswordLines = %q, want %q", lines, want)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("readPasswordLines[%d] = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestReadPasswordLinesStripsCR(t *testing.T) {
+	lines, err := readPasswordLines(strings.NewReader("one\r\ntwo\r\n"))
+	if err != nil {
+		t.Fatalf("readPasswordLines: unexpected error: %v", err)
+	}
+	want := []string{"one", "two"}
+	if len(lines) != len(want) || lines[0] != want[0] || lines[1] != want[1] {
+		t.Errorf("readPasswordLines = %q, want %q", lines, want)
+	}
+}
+
+func TestLinePassphraseProviderExhaustion(t *testing.T) {
+	p := &linePassphraseProvider{name: "test", lines: []string{"slot0", "slot1"}}
+
+	if pw, ok := p.Passphrase(0); !ok || pw != "slot0" {
+		t.Errorf("Passphrase(0) = (%q, %v), want (%q, true)", pw, ok, "slot0")
+	}
+	if pw, ok := p.Passphrase(1); !ok || pw != "slot1" {
+		t.Errorf("Passphrase(1) = (%q, %v), want (%q, true)", pw, ok, "slot1")
+	}
+	// Past the end of lines, the provider must report exhaustion rather than
+	// reusing the last line for later --unlock slots.
+	if pw, ok := p.Passphrase(2); ok {
+		t.Errorf("Passphrase(2) = (%q, %v), want ok=false", pw, ok)
+	}
+}
@@ -4,14 +4,13 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync/atomic"
 
 	"gopkg.in/urfave/cli.v1"
 
 	ethUtils "github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/accounts"
-	"github.com/ethereum/go-ethereum/accounts/keystore"
-	"github.com/ethereum/go-ethereum/console"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/log"
 
@@ -31,16 +30,51 @@ import (
 )
 
 type Services struct {
-	backend       *ethereum.Backend
-	rpcClient     *rpc.Client
-	emt           cmn.Service
-	tmNode        *node.Node
+	backend        *ethereum.Backend
+	rpcClient      *rpc.Client
+	emt            cmn.Service
+	drainApp       *drainingApp
+	tmNode         *node.Node
+	emNode         *ethereum.Node
+	accountManager *accounts.Manager
+	stopped        chan struct{}
+}
+
+// drainingApp wraps the ABCI application passed to the ABCI server with a
+// counter of in-flight DeliverTx calls, so Stop can wait on the app's real
+// drain state instead of guessing how long a fixed sleep should be.
+type drainingApp struct {
+	abcitypes.Application
+	inFlight int32
+}
+
+func newDrainingApp(app abcitypes.Application) *drainingApp {
+	return &drainingApp{Application: app}
+}
+
+func (d *drainingApp) DeliverTx(tx []byte) abcitypes.Result {
+	atomic.AddInt32(&d.inFlight, 1)
+	defer atomic.AddInt32(&d.inFlight, -1)
+	return d.Application.DeliverTx(tx)
+}
+
+// InFlight reports how many DeliverTx calls the ABCI server is currently
+// running against the wrapped application.
+func (d *drainingApp) InFlight() int32 {
+	return atomic.LoadInt32(&d.inFlight)
 }
 
 func startServices(rootDir string, storeApp *app.StoreApp) (*Services, error) {
 
 	// Step 1: Setup the go-ethereum node and start it
 	emNode := emtUtils.MakeFullNode(context)
+
+	// Pluggable backend registry: wire up any external signer (clef) or
+	// other additional accounts.Backend before accounts get unlocked.
+	if _, err := registerBackends(context, emNode.AccountManager()); err != nil {
+		ethUtils.Fatalf("Failed to register account backends: %v", err)
+	}
+
 	startNode(context, emNode)
 
 	// Setup the ABCI server and start it
@@ -67,8 +101,12 @@ func startServices(rootDir string, storeApp *app.StoreApp) (*Services, error) {
 	}
 	ethApp.SetLogger(emtUtils.EthermintLogger().With("module", "ethermint"))
 
+	// Wrap the app so Stop can watch real DeliverTx drain state rather than
+	// sleeping for a fixed window.
+	drainApp := newDrainingApp(ethApp)
+
 	// Start the app on the ABCI server
-	srv, err := server.NewServer(addr, abci, ethApp)
+	srv, err := server.NewServer(addr, abci, drainApp)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -81,6 +119,24 @@ func startServices(rootDir string, storeApp *app.StoreApp) (*Services, error) {
 		os.Exit(1)
 	}
 
+	services := &Services{
+		backend:        backend,
+		rpcClient:      rpcClient,
+		emt:            srv,
+		drainApp:       drainApp,
+		emNode:         emNode,
+		accountManager: emNode.AccountManager(),
+		stopped:        make(chan struct{}),
+	}
+
+	// When --hwwallet or --signer asked for managed validator signing, wire
+	// a PrivValidator that routes through Services.Sign instead of the
+	// plaintext priv_validator.json file.
+	privValidator, err := buildPrivValidator(context, services)
+	if err != nil {
+		ethUtils.Fatalf("Failed to set up validator signing: %v", err)
+	}
+
 	// Create Basecoin app
 	basecoinApp, err := createBaseCoinApp(rootDir, storeApp)
 	if err != nil {
@@ -88,32 +144,66 @@ func startServices(rootDir string, storeApp *app.StoreApp) (*Services, error) {
 		os.Exit(1)
 	}
 	// Create & start tendermint node
-	tmNode, err := startTendermint(basecoinApp)
+	tmNode, err := startTendermint(basecoinApp, privValidator)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	services.tmNode = tmNode
+
+	return services, nil
+}
 
-	return &Services{backend, rpcClient, srv, tmNode}, nil
+// Sign routes a signing request to whichever wallet backend holds account -
+// the plaintext keystore, an external signer, or a Ledger/Trezor opened via
+// --hwwallet. It is the entry point walletPrivValidator, the tendermint
+// PrivValidator in privvalidator.go, calls for block signing, so validator
+// keys never need to be decrypted outside the wallet.
+func (s *Services) Sign(account accounts.Account, data []byte) ([]byte, error) {
+	wallet, err := s.accountManager.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	return wallet.SignData(account, accounts.MimetypeTextPlain, data)
 }
 
-// startNode copies the logic from go-ethereum
+// startNode copies the logic from go-ethereum, generalized to a
+// manager-driven unlock flow: accounts are looked up across every
+// registered backend (keystore, external signer, hardware wallet) instead
+// of assuming the first backend is always the keystore.
 func startNode(ctx *cli.Context, stack *ethereum.Node) {
 	emtUtils.StartNode(stack)
 
-	// Unlock any account specifically requested
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	am := stack.AccountManager()
 
-	passwords := ethUtils.MakePasswordList(ctx)
-	unlocks := strings.Split(ctx.GlobalString(ethUtils.UnlockedAccountFlag.Name), ",")
-	for i, account := range unlocks {
-		if trimmed := strings.TrimSpace(account); trimmed != "" {
-			unlockAccount(ctx, ks, trimmed, i, passwords)
+	// Bring up any attached Ledger/Trezor devices alongside the keystore
+	// before unlocking, since findAccount below needs a hardware wallet's
+	// accounts already enumerated to recognize a --unlock entry that names
+	// one instead of Fatalf-ing on it.
+	if ctx.GlobalBool(HWWalletFlag.Name) {
+		watchHardwareWallets(stack)
+	}
+
+	// Unlock any account specifically requested
+	providers := buildPassphraseProviders(ctx)
+	entries := strings.Split(ctx.GlobalString(ethUtils.UnlockedAccountFlag.Name), ",")
+	opts := newUnlockOptions(len(entries))
+	for i, entry := range entries {
+		trimmed := strings.TrimSpace(entry)
+		if trimmed == "" {
+			continue
+		}
+		address, selector, err := parseUnlockEntry(trimmed)
+		if err != nil {
+			ethUtils.Fatalf("%v", err)
 		}
+		opts.Selectors[i] = selector
+		unlockAccount(ctx, am, address, i, providers, opts)
 	}
+
 	// Register wallet event handlers to open and auto-derive wallets
 	events := make(chan accounts.WalletEvent, 16)
-	stack.AccountManager().Subscribe(events)
+	am.Subscribe(events)
 
 	go func() {
 		// Create an chain state reader for self-derivation
@@ -123,8 +213,13 @@ func startNode(ctx *cli.Context, stack *ethereum.Node) {
 		}
 		stateReader := ethclient.NewClient(rpcClient)
 
-		// Open and self derive any wallets already attached
-		for _, wallet := range stack.AccountManager().Wallets() {
+		// Open and self derive any wallets already attached. Hardware
+		// wallets are handled by watchHardwareWallets instead, since they
+		// may need a PIN rather than an empty passphrase.
+		for _, wallet := range am.Wallets() {
+			if isHardwareWalletURL(wallet) {
+				continue
+			}
 			if err := wallet.Open(""); err != nil {
 				log.Warn("Failed to open wallet", "url", wallet.URL(), "err", err)
 			} else {
@@ -133,6 +228,9 @@ func startNode(ctx *cli.Context, stack *ethereum.Node) {
 		}
 		// Listen for wallet event till termination
 		for event := range events {
+			if isHardwareWalletURL(event.Wallet) {
+				continue
+			}
 			if event.Arrive {
 				if err := event.Wallet.Open(""); err != nil {
 					log.Warn("New wallet appeared, failed to open", "url",
@@ -151,102 +249,18 @@ func startNode(ctx *cli.Context, stack *ethereum.Node) {
 	}()
 }
 
-// tries unlocking the specified account a few times.
-// nolint: unparam
-func unlockAccount(ctx *cli.Context, ks *keystore.KeyStore, address string, i int,
-	passwords []string) (accounts.Account, string) {
-
-	account, err := ethUtils.MakeAddress(ks, address)
-	if err != nil {
-		ethUtils.Fatalf("Could not list accounts: %v", err)
-	}
-	for trials := 0; trials < 3; trials++ {
-		prompt := fmt.Sprintf("Unlocking account %s | Attempt %d/%d", address, trials+1, 3)
-		password := getPassPhrase(prompt, false, i, passwords)
-		err = ks.Unlock(account, password)
-		if err == nil {
-			log.Info("Unlocked account", "address", account.Address.Hex())
-			return account, password
-		}
-		if err, ok := err.(*keystore.AmbiguousAddrError); ok {
-			log.Info("Unlocked account", "address", account.Address.Hex())
-			return ambiguousAddrRecovery(ks, err, password), password
-		}
-		if err != keystore.ErrDecrypt {
-			// No need to prompt again if the error is not decryption-related.
-			break
-		}
-	}
-	// All trials expended to unlock account, bail out
-	ethUtils.Fatalf("Failed to unlock account %s (%v)", address, err)
-
-	return accounts.Account{}, ""
-}
-
-// getPassPhrase retrieves the password associated with an account, either fetched
-// from a list of preloaded passphrases, or requested interactively from the user.
-// nolint: unparam
-func getPassPhrase(prompt string, confirmation bool, i int, passwords []string) string {
-	// If a list of passwords was supplied, retrieve from them
-	if len(passwords) > 0 {
-		if i < len(passwords) {
-			return passwords[i]
-		}
-		return passwords[len(passwords)-1]
-	}
-	// Otherwise prompt the user for the password
-	if prompt != "" {
-		fmt.Println(prompt)
-	}
-	password, err := console.Stdin.PromptPassword("Passphrase: ")
-	if err != nil {
-		ethUtils.Fatalf("Failed to read passphrase: %v", err)
-	}
-	if confirmation {
-		confirm, err := console.Stdin.PromptPassword("Repeat passphrase: ")
-		if err != nil {
-			ethUtils.Fatalf("Failed to read passphrase confirmation: %v", err)
-		}
-		if password != confirm {
-			ethUtils.Fatalf("Passphrases do not match")
-		}
-	}
-	return password
-}
-
-func ambiguousAddrRecovery(ks *keystore.KeyStore, err *keystore.AmbiguousAddrError,
-	auth string) accounts.Account {
-
-	fmt.Printf("Multiple key files exist for address %x:\n", err.Addr)
-	for _, a := range err.Matches {
-		fmt.Println("  ", a.URL)
-	}
-	fmt.Println("Testing your passphrase against all of them...")
-	var match *accounts.Account
-	for _, a := range err.Matches {
-		if err := ks.Unlock(a, auth); err == nil {
-			match = &a
-			break
-		}
-	}
-	if match == nil {
-		ethUtils.Fatalf("None of the listed files could be unlocked.")
-	}
-	fmt.Printf("Your passphrase unlocked %s\n", match.URL)
-	fmt.Println("In order to avoid this warning, remove the following duplicate key files:")
-	for _, a := range err.Matches {
-		if a != *match {
-			fmt.Println("  ", a.URL)
-		}
-	}
-	return *match
-}
-
-func startTendermint(basecoinApp abcitypes.Application) (*node.Node, error) {
+// startTendermint starts the tendermint node with privVal as its
+// PrivValidator, falling back to the file-based priv_validator.json when
+// privVal is nil (i.e. neither --hwwallet nor --signer asked for managed
+// validator signing).
+func startTendermint(basecoinApp abcitypes.Application, privVal types.PrivValidator) (*node.Node, error) {
 	cfg, err := tcmd.ParseConfig()
 	if err != nil {
 		return nil, err
 	}
+	if privVal == nil {
+		privVal = types.LoadOrGenPrivValidatorFS(cfg.PrivValidatorFile())
+	}
 
 	var papp proxy.ClientCreator
 	if basecoinApp != nil {
@@ -257,7 +271,7 @@ func startTendermint(basecoinApp abcitypes.Application) (*node.Node, error) {
 
 	// Create & start tendermint node
 	n, err := node.NewNode(cfg,
-		types.LoadOrGenPrivValidatorFS(cfg.PrivValidatorFile()),
+		privVal,
 		papp,
 		node.DefaultGenesisDocProviderFunc(cfg),
 		node.DefaultDBProvider,
@@ -0,0 +1,206 @@
+package commands
+
+import (
+	stdctx "context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// GracePeriodFlag bounds how long WaitForShutdown gives Stop to tear every
+// service down cleanly once SIGINT/SIGTERM arrives before giving up on the
+// wait and letting the process exit anyway.
+var GracePeriodFlag = cli.DurationFlag{
+	Name:  "grace-period",
+	Usage: "Time allotted to shut services down cleanly on SIGINT/SIGTERM",
+	Value: 10 * time.Second,
+}
+
+// drainGracePeriod caps how long Stop waits for drainApp's in-flight
+// DeliverTx calls to actually reach zero before pulling the ABCI server out
+// from under them anyway. It is a ceiling on the real drain signal, not a
+// substitute for one.
+const drainGracePeriod = 2 * time.Second
+
+// drainPollInterval is how often Stop checks whether in-flight DeliverTx
+// calls have drained to zero.
+const drainPollInterval = 25 * time.Millisecond
+
+// drainCounter reports how many ABCI DeliverTx calls are currently running
+// against the wrapped application.
+type drainCounter interface {
+	InFlight() int32
+}
+
+// waitDrained returns a channel that closes once dc reports no in-flight
+// DeliverTx calls, polling every interval. If dc is nil there is nothing to
+// drain, so the channel is already closed.
+func waitDrained(dc drainCounter, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	if dc == nil || dc.InFlight() == 0 {
+		close(done)
+		return done
+	}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if dc.InFlight() == 0 {
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// runBounded runs fn in its own goroutine and waits for it to either finish
+// or for ctx to expire, whichever comes first. Without this, a single
+// wedged component (e.g. tendermint waiting on a stuck peer or WAL flush)
+// would block every later teardown step indefinitely, and --grace-period
+// would only ever bound the DeliverTx drain rather than the whole
+// shutdown. A component that hangs past the deadline leaves its goroutine
+// running in the background - Stop has no way to cancel fn itself - but
+// the rest of the teardown sequence is no longer stuck behind it.
+//
+// If ctx is already expired when called - because the configured grace
+// period was exhausted before this step even got a chance to run, whether
+// by an earlier step or a --grace-period of zero - fn is still started on
+// a best-effort basis, but runBounded returns immediately rather than
+// reporting fn itself as the one that hung; otherwise every step after the
+// real offender would show up as "did not stop within grace period" in
+// the joined error, making it look like every component was wedged
+// instead of just the first one.
+//
+// Either way, fn's eventual result is not lost: if it arrives after
+// runBounded has already returned, it is logged rather than discarded, so
+// an operator can still see what a slow-but-not-actually-wedged component
+// reported once it finished.
+func runBounded(ctx stdctx.Context, name string, fn func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+
+	logLateResult := func() {
+		if err := <-done; err != nil {
+			log.Warn("Component stopped after grace period expired", "component", name, "err", err)
+		}
+	}
+
+	if ctx.Err() != nil {
+		go logLateResult()
+		return fmt.Errorf("%s: skipped, grace period already exhausted before this step could run", name)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		return nil
+	case <-ctx.Done():
+		go logLateResult()
+		return fmt.Errorf("%s: did not stop within grace period: %v", name, ctx.Err())
+	}
+}
+
+// Stop tears every service down in the order that keeps consensus,
+// execution, and storage consistent: tendermint stops first so no new
+// blocks get proposed, in-flight DeliverTx calls are watched via drainApp
+// until they actually reach zero or drainGracePeriod elapses, then the ABCI
+// server, inproc RPC client, and ethereum node follow. ctx bounds the whole
+// call, including each individual teardown step via runBounded, not just
+// the DeliverTx drain; on expiry Stop carries on stopping what it can
+// rather than leaving later services dangling. The returned error joins
+// every failure encountered instead of stopping at the first one, so a
+// SIGTERM handler can report everything that didn't shut down cleanly.
+func (s *Services) Stop(ctx stdctx.Context) error {
+	defer close(s.stopped)
+
+	var errs []error
+
+	if s.tmNode != nil && s.tmNode.IsRunning() {
+		if err := runBounded(ctx, "tendermint node", s.tmNode.Stop); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var drainApp drainCounter
+	if s.drainApp != nil {
+		drainApp = s.drainApp
+	}
+	select {
+	case <-waitDrained(drainApp, drainPollInterval):
+	case <-time.After(drainGracePeriod):
+		log.Warn("In-flight transactions did not drain within grace period", "grace", drainGracePeriod)
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("in-flight transactions: %v", ctx.Err()))
+	}
+
+	if s.emt != nil {
+		if err := runBounded(ctx, "abci server", s.emt.Stop); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if s.rpcClient != nil {
+		s.rpcClient.Close()
+	}
+
+	if s.emNode != nil {
+		if err := runBounded(ctx, "ethereum node", s.emNode.Stop); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return multiError(errs)
+}
+
+// Wait blocks until Stop has finished tearing every service down, so a
+// supervisor knows it is safe to exit once this returns.
+func (s *Services) Wait() {
+	<-s.stopped
+}
+
+// WaitForShutdown blocks until SIGINT or SIGTERM, then stops services
+// within the grace period configured by --grace-period. The start command
+// should call this right after startServices returns, and should exit
+// immediately once it does - any component still tearing down past the
+// grace period keeps running in a detached goroutine (see runBounded) and
+// is not given a further chance to finish before the process exits.
+func WaitForShutdown(ctx *cli.Context, services *Services) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	sig := <-sigCh
+	log.Info("Received shutdown signal, stopping services", "signal", sig)
+
+	grace := ctx.GlobalDuration(GracePeriodFlag.Name)
+	stopCtx, cancel := stdctx.WithTimeout(stdctx.Background(), grace)
+	defer cancel()
+
+	if err := services.Stop(stopCtx); err != nil {
+		log.Error("Error while stopping services", "err", err)
+	}
+	services.Wait()
+}
+
+// multiError joins zero or more errors into one, so Stop can report every
+// component that failed to shut down instead of only the first.
+type multiError []error
+
+func (m multiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	stdctx "context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunBoundedReturnsFnError(t *testing.T) {
+	ctx, cancel := stdctx.WithTimeout(stdctx.Background(), time.Second)
+	defer cancel()
+
+	want := errors.New("boom")
+	err := runBounded(ctx, "widget", func() error { return want })
+	if err == nil {
+		t.Fatal("runBounded: expected error, got nil")
+	}
+	if got := err.Error(); got != "widget: boom" {
+		t.Errorf("runBounded: err = %q, want %q", got, "widget: boom")
+	}
+}
+
+func TestRunBoundedReturnsNilOnSuccess(t *testing.T) {
+	ctx, cancel := stdctx.WithTimeout(stdctx.Background(), time.Second)
+	defer cancel()
+
+	if err := runBounded(ctx, "widget", func() error { return nil }); err != nil {
+		t.Errorf("runBounded: unexpected error: %v", err)
+	}
+}
+
+func TestRunBoundedSkipsWhenGracePeriodAlreadyExhausted(t *testing.T) {
+	ctx, cancel := stdctx.WithCancel(stdctx.Background())
+	cancel() // simulate an earlier Stop step having already used up ctx
+
+	called := make(chan struct{}, 1)
+	err := runBounded(ctx, "widget", func() error {
+		called <- struct{}{}
+		return nil
+	})
+
+	if err == nil {
+		t.Fatal("runBounded: expected a skipped-step error, got nil")
+	}
+	want := "widget: skipped, grace period already exhausted before this step could run"
+	if got := err.Error(); got != want {
+		t.Errorf("runBounded: err = %q, want %q", got, want)
+	}
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Error("runBounded: fn was never started even on a best-effort basis")
+	}
+}
+
+func TestRunBoundedTimesOutOnHungFn(t *testing.T) {
+	ctx, cancel := stdctx.WithTimeout(stdctx.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	started := make(chan struct{})
+	err := runBounded(ctx, "widget", func() error {
+		close(started)
+		select {} // simulate a component that never returns
+	})
+	<-started
+
+	if err == nil {
+		t.Fatal("runBounded: expected timeout error, got nil")
+	}
+	if got := err.Error(); got != "widget: did not stop within grace period: context deadline exceeded" {
+		t.Errorf("runBounded: err = %q, want a grace-period timeout message", got)
+	}
+}
+
+type fakeDrainCounter struct {
+	inFlight int32
+}
+
+func (f *fakeDrainCounter) InFlight() int32 {
+	return atomic.LoadInt32(&f.inFlight)
+}
+
+func TestWaitDrainedClosesImmediatelyWhenNilOrEmpty(t *testing.T) {
+	select {
+	case <-waitDrained(nil, time.Millisecond):
+	case <-time.After(time.Second):
+		t.Fatal("waitDrained: did not close immediately for a nil counter")
+	}
+
+	select {
+	case <-waitDrained(&fakeDrainCounter{}, time.Millisecond):
+	case <-time.After(time.Second):
+		t.Fatal("waitDrained: did not close immediately for an already-drained counter")
+	}
+}
+
+func TestWaitDrainedWaitsForInFlightToReachZero(t *testing.T) {
+	dc := &fakeDrainCounter{inFlight: 1}
+	done := waitDrained(dc, time.Millisecond)
+
+	select {
+	case <-done:
+		t.Fatal("waitDrained: closed before in-flight count reached zero")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	atomic.StoreInt32(&dc.inFlight, 0)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("waitDrained: did not close after in-flight count reached zero")
+	}
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/CyberMiles/travis/app"
+)
+
+// StartCmd boots every travis service (ethereum node, ABCI server,
+// tendermint node) and blocks until a shutdown signal stops them cleanly.
+var StartCmd = cli.Command{
+	Name:  "start",
+	Usage: "Start this validator node",
+	Flags: []cli.Flag{
+		HWWalletFlag,
+		SignerFlag,
+		ValidatorAccountFlag,
+		PasswordEnvFlag,
+		PasswordFDFlag,
+		GracePeriodFlag,
+	},
+	Action: startCmd,
+}
+
+func startCmd(ctx *cli.Context) error {
+	context = ctx
+
+	rootDir := ctx.GlobalString("home")
+	storeApp, err := app.NewStoreApp(rootDir)
+	if err != nil {
+		return err
+	}
+
+	services, err := startServices(rootDir, storeApp)
+	if err != nil {
+		return err
+	}
+
+	// Blocks until SIGINT/SIGTERM, then stops every service within
+	// --grace-period before returning.
+	WaitForShutdown(ctx, services)
+	return nil
+}
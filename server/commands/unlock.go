@@ -0,0 +1,208 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/urfave/cli.v1"
+
+	ethUtils "github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/console"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// UnlockOptions carries the per-entry URL selectors parsed from --unlock, so
+// an address that exists in more than one key file can be disambiguated
+// without auto-unlocking every match.
+type UnlockOptions struct {
+	// Selectors holds one parsed URL selector per --unlock entry, indexed
+	// the same way as the comma-separated address list. A nil entry means
+	// the user will be asked to choose interactively if the address turns
+	// out to be ambiguous.
+	Selectors []*accounts.URL
+}
+
+func newUnlockOptions(n int) *UnlockOptions {
+	return &UnlockOptions{Selectors: make([]*accounts.URL, n)}
+}
+
+// parseUnlockEntry splits a single --unlock entry into the requested
+// address and, if present, a "0xADDR@scheme://path" selector disambiguating
+// which key file to use when multiple share that address.
+func parseUnlockEntry(entry string) (address string, selector *accounts.URL, err error) {
+	parts := strings.SplitN(entry, "@", 2)
+	address = strings.TrimSpace(parts[0])
+	if len(parts) == 1 {
+		return address, nil, nil
+	}
+	url, err := parseAccountURL(parts[1])
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid selector in --unlock entry %q: %v", entry, err)
+	}
+	return address, &url, nil
+}
+
+// parseAccountURL parses a "scheme://path" selector into an accounts.URL.
+// accounts.parseURL does the same thing but is unexported, so this mirrors
+// it locally rather than reaching into go-ethereum's internals.
+func parseAccountURL(url string) (accounts.URL, error) {
+	parts := strings.SplitN(url, "://", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return accounts.URL{}, fmt.Errorf("protocol scheme missing in %q", url)
+	}
+	return accounts.URL{Scheme: parts[0], Path: parts[1]}, nil
+}
+
+// findAccount looks address up across every wallet registered with am,
+// rather than assuming the account lives in a particular backend.
+func findAccount(am *accounts.Manager, address string) (accounts.Account, accounts.Wallet, error) {
+	for _, wallet := range am.Wallets() {
+		for _, account := range wallet.Accounts() {
+			if strings.EqualFold(account.Address.Hex(), address) {
+				return account, wallet, nil
+			}
+		}
+	}
+	return accounts.Account{}, nil, fmt.Errorf("no account %s found in any registered backend", address)
+}
+
+// keystoreBackend returns the keystore backend registered with am, if any.
+// A pure --signer/HSM deployment may not register one at all, so callers
+// must check ok rather than assuming am.Backends(keystore.KeyStoreType) is
+// non-empty.
+func keystoreBackend(am *accounts.Manager) (ks *keystore.KeyStore, ok bool) {
+	backends := am.Backends(keystore.KeyStoreType)
+	if len(backends) == 0 {
+		return nil, false
+	}
+	ks, ok = backends[0].(*keystore.KeyStore)
+	return ks, ok
+}
+
+// maxAttemptsPerProvider caps how many passphrases unlockAccount will try
+// from a single PassphraseProvider for one account before moving on to the
+// next provider in the chain.
+const maxAttemptsPerProvider = 3
+
+// passphraseBackoffFloor is the initial delay after an ErrDecrypt; it
+// doubles on every subsequent rejection from the same provider.
+const passphraseBackoffFloor = 250 * time.Millisecond
+
+// tries unlocking the specified account against each PassphraseProvider in
+// turn, backing off exponentially between rejected guesses and moving to
+// the next provider once one is exhausted. Only keystore-backed accounts
+// take a passphrase here; external signer and hardware wallet accounts
+// authenticate on their own terms during registerBackends /
+// watchHardwareWallets, so unlocking them is a no-op.
+func unlockAccount(ctx *cli.Context, am *accounts.Manager, address string, i int,
+	providers []PassphraseProvider, opts *UnlockOptions) (accounts.Account, string) {
+
+	account, wallet, err := findAccount(am, address)
+	if err != nil {
+		ethUtils.Fatalf("Could not list accounts: %v", err)
+	}
+	ks, hasKeystore := keystoreBackend(am)
+	if !hasKeystore || !ks.HasAddress(account.Address) {
+		log.Info("Account backed by remote signer, nothing to unlock",
+			"address", account.Address.Hex(), "url", wallet.URL())
+		return account, ""
+	}
+
+	for _, provider := range providers {
+		// Deterministic providers (file/env/fd) return the same answer for
+		// a slot on every call, so retrying one is pure wasted backoff;
+		// only the interactive prompt can produce a different guess.
+		attempts := 1
+		if _, interactive := provider.(stdinPassphraseProvider); interactive {
+			attempts = maxAttemptsPerProvider
+		}
+		backoff := passphraseBackoffFloor
+		for attempt := 0; attempt < attempts; attempt++ {
+			password, ok := provider.Passphrase(i)
+			if !ok {
+				break
+			}
+			// Unlock by address only: the account from findAccount carries
+			// one key file's URL, which would hide an ambiguous address.
+			err = ks.Unlock(accounts.Account{Address: account.Address}, password)
+			if err == nil {
+				log.Info("Unlocked account", "address", account.Address.Hex(), "via", provider.Name())
+				return account, password
+			}
+			if ambErr, ok := err.(*keystore.AmbiguousAddrError); ok {
+				return ambiguousAddrRecovery(ks, ambErr, opts.Selectors[i], password), password
+			}
+			if err != keystore.ErrDecrypt {
+				ethUtils.Fatalf("Failed to unlock account %s: %v", address, err)
+			}
+			log.Warn("Passphrase rejected, backing off", "address", address,
+				"via", provider.Name(), "attempt", attempt+1, "backoff", backoff)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	// Every provider ran out of guesses without a match, bail out.
+	ethUtils.Fatalf("Failed to unlock account %s: all passphrase providers exhausted (%v)", address, err)
+
+	return accounts.Account{}, ""
+}
+
+// selectAmbiguousMatch returns the candidate whose URL equals selector, or
+// nil if selector is nil or matches none of them.
+func selectAmbiguousMatch(candidates []accounts.Account, selector *accounts.URL) *accounts.Account {
+	if selector == nil {
+		return nil
+	}
+	for _, a := range candidates {
+		if a.URL == *selector {
+			match := a
+			return &match
+		}
+	}
+	return nil
+}
+
+// ambiguousAddrRecovery resolves a keystore.AmbiguousAddrError to a single
+// account. When selector is non-nil it must exactly match one of the
+// candidates; otherwise the user is asked to pick one interactively via
+// console.Stdin. Only the chosen match is ever unlocked - unlike the old
+// behaviour of testing auth against every file, the rest are left encrypted.
+func ambiguousAddrRecovery(ks *keystore.KeyStore, err *keystore.AmbiguousAddrError,
+	selector *accounts.URL, auth string) accounts.Account {
+
+	if selector != nil {
+		match := selectAmbiguousMatch(err.Matches, selector)
+		if match == nil {
+			ethUtils.Fatalf("Selector %s did not match any key file for address %x", selector, err.Addr)
+		}
+		if uErr := ks.Unlock(*match, auth); uErr != nil {
+			ethUtils.Fatalf("Failed to unlock %s: %v", match.URL, uErr)
+		}
+		log.Info("Unlocked account", "address", match.Address.Hex(), "url", match.URL)
+		return *match
+	}
+
+	fmt.Printf("Multiple key files exist for address %x:\n", err.Addr)
+	for i, a := range err.Matches {
+		fmt.Printf("  %d) %s\n", i, a.URL)
+	}
+	choice, pErr := console.Stdin.Prompt("Enter the number of the key file to use: ")
+	if pErr != nil {
+		ethUtils.Fatalf("Failed to read selection: %v", pErr)
+	}
+	idx, convErr := strconv.Atoi(strings.TrimSpace(choice))
+	if convErr != nil || idx < 0 || idx >= len(err.Matches) {
+		ethUtils.Fatalf("Invalid selection %q", choice)
+	}
+	match := err.Matches[idx]
+	if uErr := ks.Unlock(match, auth); uErr != nil {
+		ethUtils.Fatalf("Failed to unlock %s: %v", match.URL, uErr)
+	}
+	fmt.Printf("Unlocked %s\n", match.URL)
+	fmt.Println("In order to avoid this prompt, pass a selector: 0xADDR@" + match.URL.String())
+	return match
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestParseUnlockEntry(t *testing.T) {
+	tests := []struct {
+		name       string
+		entry      string
+		wantAddr   string
+		wantScheme string
+		wantErr    bool
+	}{
+		{
+			name:     "plain address",
+			entry:    "0x0000000000000000000000000000000000000001",
+			wantAddr: "0x0000000000000000000000000000000000000001",
+		},
+		{
+			name:     "address with surrounding whitespace",
+			entry:    "  0x0000000000000000000000000000000000000001  ",
+			wantAddr: "0x0000000000000000000000000000000000000001",
+		},
+		{
+			name:       "address with selector",
+			entry:      "0x0000000000000000000000000000000000000001@keystore:///path/to/key",
+			wantAddr:   "0x0000000000000000000000000000000000000001",
+			wantScheme: "keystore",
+		},
+		{
+			name:    "invalid selector",
+			entry:   "0x0000000000000000000000000000000000000001@not a url::",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, selector, err := parseUnlockEntry(tt.entry)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseUnlockEntry(%q): expected error, got nil", tt.entry)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseUnlockEntry(%q): unexpected error: %v", tt.entry, err)
+			}
+			if address != tt.wantAddr {
+				t.Errorf("parseUnlockEntry(%q): address = %q, want %q", tt.entry, address, tt.wantAddr)
+			}
+			if tt.wantScheme == "" {
+				if selector != nil {
+					t.Errorf("parseUnlockEntry(%q): selector = %v, want nil", tt.entry, selector)
+				}
+				return
+			}
+			if selector == nil {
+				t.Fatalf("parseUnlockEntry(%q): selector = nil, want scheme %q", tt.entry, tt.wantScheme)
+			}
+			if selector.Scheme != tt.wantScheme {
+				t.Errorf("parseUnlockEntry(%q): selector.Scheme = %q, want %q", tt.entry, selector.Scheme, tt.wantScheme)
+			}
+		})
+	}
+}
+
+func TestSelectAmbiguousMatch(t *testing.T) {
+	urlA := accounts.URL{Scheme: "keystore", Path: "/a"}
+	urlB := accounts.URL{Scheme: "keystore", Path: "/b"}
+	candidates := []accounts.Account{
+		{Address: common.HexToAddress("0x1"), URL: urlA},
+		{Address: common.HexToAddress("0x2"), URL: urlB},
+	}
+
+	if got := selectAmbiguousMatch(candidates, nil); got != nil {
+		t.Errorf("selectAmbiguousMatch with nil selector = %v, want nil", got)
+	}
+
+	match := selectAmbiguousMatch(candidates, &urlB)
+	if match == nil {
+		t.Fatal("selectAmbiguousMatch: expected a match for urlB, got nil")
+	}
+	if match.URL != urlB {
+		t.Errorf("selectAmbiguousMatch: matched %v, want %v", match.URL, urlB)
+	}
+
+	noMatch := accounts.URL{Scheme: "keystore", Path: "/nowhere"}
+	if got := selectAmbiguousMatch(candidates, &noMatch); got != nil {
+		t.Errorf("selectAmbiguousMatch with non-matching selector = %v, want nil", got)
+	}
+}